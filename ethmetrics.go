@@ -2,24 +2,26 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"net"
+	"net/http"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/dmuino/ethtoolMetrics/internal/ethstats"
+	"github.com/dmuino/ethtoolMetrics/internal/logging"
+	"github.com/dmuino/ethtoolMetrics/internal/sinks"
 )
 
-type measurement struct {
-	name  string
-	tags  map[string]string
-	value int64
-}
+type measurement = sinks.Measurement
 
 // convert snake_case to camelCase
 func toCamelCase(s string) string {
@@ -39,8 +41,8 @@ func getQueueMetric(ms *measurement, name string) bool {
 	if len(match) != 4 {
 		return false
 	}
-	ms.name = "eth.queue." + toCamelCase(match[3])
-	ms.tags = map[string]string{"queue": match[1], "dir": match[2]}
+	ms.Name = "eth.queue." + toCamelCase(match[3])
+	ms.Tags = map[string]string{"queue": match[1], "dir": match[2]}
 	return true
 }
 
@@ -50,8 +52,8 @@ func getRxTxMetric(ms *measurement, name string) bool {
 	if len(match) != 3 {
 		return false
 	}
-	ms.name = "eth." + toCamelCase(match[2])
-	ms.tags = map[string]string{"dir": match[1]}
+	ms.Name = "eth." + toCamelCase(match[2])
+	ms.Tags = map[string]string{"dir": match[1]}
 	return true
 }
 
@@ -71,7 +73,7 @@ func getMeasurement(line string) (measurement, bool) {
 	if err != nil {
 		return ms, false
 	}
-	ms.value = val
+	ms.Value = val
 	done := false
 	if strings.HasPrefix(name, "queue_") {
 		done = getQueueMetric(&ms, name)
@@ -80,111 +82,183 @@ func getMeasurement(line string) (measurement, bool) {
 		done = getRxTxMetric(&ms, name)
 	}
 	if !done {
-		ms.name = "eth." + toCamelCase(name)
+		ms.Name = "eth." + toCamelCase(name)
 	}
 	return ms, true
 }
 
-func getStats(dev string) (string, error) {
+// getStats gathers interface statistics, preferring the native ioctl-based
+// ethstats collector and falling back to shelling out to ethtool -S when
+// that's unsupported (non-Linux, missing CAP_NET_ADMIN, older kernels).
+func getStats(dev string) ([]measurement, error) {
+	stats, err := ethstats.Gather(dev)
+	if err == nil {
+		return fromEthstats(stats, dev), nil
+	}
+	if !errors.Is(err, ethstats.ErrUnsupported) {
+		logging.Warnf("Native ethtool collection failed for %s, falling back to exec: %v", dev, err)
+	}
+	return getStatsExec(dev)
+}
+
+func getStatsExec(dev string) ([]measurement, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "ethtool", "-S", "eth0")
+	cmd := exec.CommandContext(ctx, "ethtool", "-S", dev)
 	out, err := cmd.Output()
 	if ctx.Err() == context.DeadlineExceeded {
-		return "", fmt.Errorf("Timed out getting statistics for interface %s\n", dev)
+		return nil, fmt.Errorf("Timed out getting statistics for interface %s", dev)
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(out), nil
+	logging.Tagged(logging.Net, "ethtool -S %s:\n%s", dev, out)
+	return toMeasurements(string(out), dev), nil
 }
 
-func toMeasurements(ethtool string) []measurement {
+// fromEthstats reuses the existing queue/rx/tx naming conventions by
+// re-running each stat's name through getMeasurement, just as if it had
+// come from an `ethtool -S` line.
+func fromEthstats(stats []ethstats.Stat, dev string) []measurement {
+	res := make([]measurement, 0, len(stats))
+	for _, st := range stats {
+		m, ok := getMeasurement(fmt.Sprintf("%s: %d", st.Name, st.Value))
+		if !ok {
+			continue
+		}
+		if m.Tags == nil {
+			m.Tags = map[string]string{}
+		}
+		m.Tags["iface"] = dev
+		logging.Tagged(logging.Parse, "parsed %+v", m)
+		res = append(res, m)
+	}
+	return res
+}
+
+func toMeasurements(ethtool string, dev string) []measurement {
 	var res []measurement
 	scanner := bufio.NewScanner(strings.NewReader(ethtool))
 	for scanner.Scan() {
 		m, ok := getMeasurement(scanner.Text())
 		if ok {
+			if m.Tags == nil {
+				m.Tags = map[string]string{}
+			}
+			m.Tags["iface"] = dev
+			logging.Tagged(logging.Parse, "parsed %+v", m)
 			res = append(res, m)
 		}
 	}
 	return res
 }
 
-func toSpectatord(ms measurement) []byte {
-	var b bytes.Buffer
-	b.Grow(32)
-	b.WriteString("C:")
-	b.WriteString(ms.name)
-	for k, v := range ms.tags {
-		b.WriteByte(',')
-		b.WriteString(k)
-		b.WriteByte('=')
-		b.WriteString(v)
-	}
-	b.WriteByte(':')
-	b.WriteString(strconv.FormatInt(ms.value, 10))
-	b.WriteByte('\n')
-	return b.Bytes()
+// prometheusName converts a dotted measurement name (eth.queue.txPackets)
+// into the underscore form Prometheus expects (eth_queue_txPackets).
+func prometheusName(name string) string {
+	return strings.ReplaceAll(name, ".", "_")
 }
 
-func measurementsToSpectatord(ms []measurement) [][]byte {
-	res := make([][]byte, len(ms))
-	for i, m := range ms {
-		res[i] = toSpectatord(m)
+func prometheusLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
 	}
-	return res
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%q", k, tags[k])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
 }
 
-func getInterfaces() ([]string, error) {
-	var res []string
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, err
+// writePrometheus renders measurements in the Prometheus text exposition
+// format, grouping samples under a single "# TYPE" line per metric name.
+func writePrometheus(w *bufio.Writer, ms []measurement) {
+	var order []string
+	byName := make(map[string][]measurement)
+	for _, m := range ms {
+		name := prometheusName(m.Name)
+		if _, ok := byName[name]; !ok {
+			order = append(order, name)
+		}
+		byName[name] = append(byName[name], m)
 	}
-	for _, iface := range interfaces {
-		if iface.Flags&net.FlagLoopback == 0 && !strings.HasPrefix(iface.Name, "docker") {
-			res = append(res, iface.Name)
+	for _, name := range order {
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, m := range byName[name] {
+			fmt.Fprintf(w, "%s%s %d\n", name, prometheusLabels(m.Tags), m.Value)
 		}
 	}
-	return res, nil
 }
 
-const SpectatordAddress = "127.0.0.1:1234"
+// metricsCache re-runs getStats/toMeasurements on demand but remembers the
+// result for a short ttl so that back-to-back scrapes don't hammer ethtool.
+type metricsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	expires time.Time
+	ms      []measurement
+}
 
-type SpectatordSender struct {
-	address string
-	c net.Conn
+func (c *metricsCache) get(ifaces []string) []measurement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.expires) {
+		return c.ms
+	}
+	var ms []measurement
+	for _, dev := range ifaces {
+		stats, err := getStats(dev)
+		if err != nil {
+			logging.Warnf("Unable to get stats for %s: %v", dev, err)
+			continue
+		}
+		ms = append(ms, stats...)
+	}
+	c.ms = ms
+	c.expires = time.Now().Add(c.ttl)
+	return c.ms
 }
 
-func (s *SpectatordSender) initConn() (err error) {
-	if s.c != nil {
-		_ = s.c.Close()
+const metricsCacheTTL = 2 * time.Second
+
+func metricsHandler(cache *metricsCache, ifaces []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		bw := bufio.NewWriter(w)
+		writePrometheus(bw, cache.get(ifaces))
+		bw.Flush()
 	}
-	s.c, err = net.Dial("udp", s.address)
-	return err
 }
 
-func NewSpectatordSender(address string) (*SpectatordSender, error) {
-	s := SpectatordSender{address, nil}
-	err := s.initConn()
-	return &s, err
+// serveMetrics serves the /metrics HTTP endpoint and blocks while doing so,
+// so callers should invoke it in a goroutine; if the listener fails it
+// exits the process.
+func serveMetrics(listen string, ifaces []string) {
+	cache := &metricsCache{ttl: metricsCacheTTL}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler(cache, ifaces))
+	logging.Infof("Serving Prometheus metrics on %s/metrics", listen)
+	logging.Fatalf("Prometheus listener failed: %v", http.ListenAndServe(listen, mux))
 }
 
-func (s *SpectatordSender) sendBatch(batch [][]byte) (err error) {
-	chunk := bytes.Join(batch, nil)
-	for retry := 1; retry <= 3; retry++ {
-		_, err = s.c.Write(chunk)
-		if err == nil {
-			return
-		}
-		err = s.initConn() // close and reopen the connection before retrying
-		if err != nil {
-			return
+func getInterfaces() ([]string, error) {
+	var res []string
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 && !strings.HasPrefix(iface.Name, "docker") {
+			res = append(res, iface.Name)
 		}
 	}
-	return
+	return res, nil
 }
 
 func min(a int, b int) int {
@@ -194,24 +268,59 @@ func min(a int, b int) int {
 	return b
 }
 
-func (s *SpectatordSender) SendUpdates(updates [][]byte) error {
-	beg := 0
-	end := len(updates)
-	for beg < end {
-		cur := min(beg + 32, end)
-		err := s.sendBatch(updates[beg : cur])
+// worker pulls interface names off jobs, gathers and sends their stats, and
+// reports the number of updates sent on results. A getStats failure is
+// transient (a flaky NIC, a missed ioctl) so it's logged and skipped
+// rather than killing the daemon.
+func worker(s sinks.Sink, jobs <-chan string, results chan<- int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for dev := range jobs {
+		logging.Debugf("Gathering ethtool metrics for %s", dev)
+		ms, err := getStats(dev)
 		if err != nil {
-			return err
+			logging.Warnf("Unable to gather stats for %s: %v", dev, err)
+			results <- 0
+			continue
 		}
-		beg = cur
+		if err := s.Send(ms); err != nil {
+			logging.Warnf("Unable to send batch of %d updates for %s: %v", len(ms), dev, err)
+			results <- 0
+			continue
+		}
+		results <- len(ms)
+	}
+}
+
+// runCycle fans ifaces out across concurrency workers and returns the total
+// number of updates sent during the cycle.
+func runCycle(s sinks.Sink, ifaces []string, concurrency int) int {
+	jobs := make(chan string, len(ifaces))
+	results := make(chan int, len(ifaces))
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go worker(s, jobs, results, &wg)
 	}
-	return nil
+	for _, dev := range ifaces {
+		jobs <- dev
+	}
+	close(jobs)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	total := 0
+	for n := range results {
+		total += n
+	}
+	return total
 }
 
 func getDefaultInterfaces() string {
 	ifaces, err := getInterfaces()
 	if err != nil {
-		log.Fatal("Unable to get interfaces", err)
+		logging.Fatalf("Unable to get interfaces: %v", err)
 	}
 	return strings.Join(ifaces, ",")
 }
@@ -219,38 +328,44 @@ func getDefaultInterfaces() string {
 func main() {
 	ifacesStr := getDefaultInterfaces()
 	ifacesFlag := flag.String("ifaces", ifacesStr, "Comma separated list of interfaces to query")
-	addresssFlag := flag.String("address", SpectatordAddress, "hostname:port where spectatord is listening")
-	freqFlag := flag.Duration("frequency", 30 * time.Second, "Collect metrics at this frequency")
+	sinkFlag := flag.String("sink", "spectatord", "Output sink: spectatord, influx, or statsd")
+	addresssFlag := flag.String("address", sinks.DefaultSpectatordAddress, "Address/URL for the selected sink")
+	freqFlag := flag.Duration("frequency", 30*time.Second, "Collect metrics at this frequency")
+	listenFlag := flag.String("listen", "", "If set, serve Prometheus metrics on this address (e.g. :9101) at /metrics")
+	concurrencyFlag := flag.Int("concurrency", min(4, len(strings.Split(ifacesStr, ","))),
+		"Number of interfaces to scrape concurrently")
+	bufferFlag := flag.Int("buffer", sinks.DefaultBufferCapacity, "Number of updates to buffer across sink outages")
+	spoolDirFlag := flag.String("spool-dir", "", "If set, spool buffer overflow to disk under this directory")
+	logLevelFlag := flag.String("log-level", "info", "Minimum log level: debug, info, warn, or error")
 	flag.Parse()
+	logging.SetLevel(*logLevelFlag)
 
-	s, err := NewSpectatordSender(*addresssFlag)
+	s, err := sinks.New(*sinkFlag, *addresssFlag)
 	if err != nil {
-		log.Fatal("Unable to send metrics to spectatord", err)
+		logging.Fatalf("Unable to create sink: %v", err)
+	}
+	s, err = sinks.NewBufferedSink(s, *bufferFlag, *spoolDirFlag)
+	if err != nil {
+		logging.Fatalf("Unable to create buffer: %v", err)
 	}
 
 	ifaces := strings.Split(*ifacesFlag, ",")
+	concurrency := min(*concurrencyFlag, len(ifaces))
+
+	if *listenFlag != "" {
+		go serveMetrics(*listenFlag, ifaces)
+	}
+
 	for {
 		start := time.Now()
-		for _, dev := range ifaces {
-			log.Printf("Gathering ethtool metrics for %s", dev)
-			ethtool, err := getStats(dev)
-			if err != nil {
-				log.Fatal(err)
-			}
-			ms := toMeasurements(ethtool)
-			updates := measurementsToSpectatord(ms)
-			err = s.SendUpdates(updates)
-			if err != nil {
-				log.Printf("Unable to send batch of %d updates: %v", len(updates), err)
-			}
-		}
+		total := runCycle(s, ifaces, concurrency)
 		elapsed := time.Since(start)
 		toSleep := *freqFlag - elapsed
 		devStr := "interface"
 		if len(ifaces) > 1 {
 			devStr = "interfaces"
 		}
-		log.Printf("Done processing metrics for %d %s in %v. Sleeping %v", len(ifaces),
+		logging.Infof("Sent %d updates for %d %s in %v. Sleeping %v", total, len(ifaces),
 			devStr, elapsed, toSleep)
 		time.Sleep(toSleep)
 	}
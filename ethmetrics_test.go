@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dmuino/ethtoolMetrics/internal/ethstats"
+)
+
+func TestFromEthstats(t *testing.T) {
+	cases := []struct {
+		name string
+		stat ethstats.Stat
+		want measurement
+	}{
+		{
+			name: "queue metric",
+			stat: ethstats.Stat{Name: "queue_0_tx_unmask_interrupt", Value: 7},
+			want: measurement{
+				Name:  "eth.queue.unmaskInterrupt",
+				Value: 7,
+				Tags:  map[string]string{"queue": "0", "dir": "tx", "iface": "eth0"},
+			},
+		},
+		{
+			name: "rx/tx metric",
+			stat: ethstats.Stat{Name: "rx_packets", Value: 42},
+			want: measurement{
+				Name:  "eth.packets",
+				Value: 42,
+				Tags:  map[string]string{"dir": "rx", "iface": "eth0"},
+			},
+		},
+		{
+			name: "plain metric",
+			stat: ethstats.Stat{Name: "alloc_rx_buff_failed", Value: 3},
+			want: measurement{
+				Name:  "eth.allocRxBuffFailed",
+				Value: 3,
+				Tags:  map[string]string{"iface": "eth0"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := fromEthstats([]ethstats.Stat{c.stat}, "eth0")
+			if len(got) != 1 {
+				t.Fatalf("fromEthstats() returned %d measurements, want 1", len(got))
+			}
+			if !reflect.DeepEqual(got[0], c.want) {
+				t.Errorf("fromEthstats() = %+v, want %+v", got[0], c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// Package ethstats gathers ethtool -S-equivalent driver statistics without
+// shelling out to the ethtool binary.
+package ethstats
+
+import "errors"
+
+// Stat is a single named statistic, in the same order and with the same
+// names ethtool -S would report for the interface.
+type Stat struct {
+	Name  string
+	Value uint64
+}
+
+// ErrUnsupported is returned when native stats collection isn't available
+// on this platform, the process lacks the required capability, or the
+// driver doesn't expose a stats string set. Callers should fall back to
+// shelling out to the ethtool binary.
+var ErrUnsupported = errors.New("ethstats: native stats collection not supported")
@@ -0,0 +1,161 @@
+//go:build linux
+
+package ethstats
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl op codes and string-set id from linux/ethtool.h and linux/sockios.h.
+const (
+	siocEthtool      = 0x8946
+	ethtoolGSSetInfo = 0x00000037
+	ethtoolGStrings  = 0x0000001b
+	ethtoolGStats    = 0x0000001d
+	ethStringLen     = 32
+	ethSSStats       = 1
+)
+
+// ifreq mirrors struct ifreq with the trailing union replaced by a pointer,
+// which is how SIOCETHTOOL expects ifr_data to be populated on 64-bit Linux.
+type ifreq struct {
+	name [16]byte
+	data unsafe.Pointer
+}
+
+type ethtoolSSetInfo struct {
+	cmd      uint32
+	reserved uint32
+	sSetMask uint64
+	count    uint32
+}
+
+type ethtoolGStringsHdr struct {
+	cmd       uint32
+	stringSet uint32
+	length    uint32
+}
+
+type ethtoolStatsHdr struct {
+	cmd    uint32
+	nStats uint32
+}
+
+// Gather reads driver statistics for dev via the SIOCETHTOOL ioctl on an
+// AF_INET socket, equivalent to `ethtool -S dev` but without forking a
+// process or parsing its output.
+func Gather(dev string) ([]Stat, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ethstats: socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	n, err := statsCount(fd, dev)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrUnsupported
+	}
+
+	names, err := gatherStrings(fd, dev, n)
+	if err != nil {
+		return nil, err
+	}
+	values, err := gatherStats(fd, dev, n)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]Stat, n)
+	for i := uint32(0); i < n; i++ {
+		stats[i] = Stat{Name: names[i], Value: values[i]}
+	}
+	return stats, nil
+}
+
+func doIoctl(fd int, dev string, payload unsafe.Pointer) error {
+	var req ifreq
+	copy(req.name[:], dev)
+	req.data = payload
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&req)))
+	if errno == syscall.EPERM || errno == syscall.EOPNOTSUPP {
+		return fmt.Errorf("%w: %v", ErrUnsupported, errno)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// statsCount asks the driver how many entries are in its ETH_SS_STATS
+// string set; that count also bounds ETHTOOL_GSTRINGS/ETHTOOL_GSTATS.
+func statsCount(fd int, dev string) (uint32, error) {
+	info := ethtoolSSetInfo{cmd: ethtoolGSSetInfo, sSetMask: 1 << ethSSStats}
+	if err := doIoctl(fd, dev, unsafe.Pointer(&info)); err != nil {
+		return 0, err
+	}
+	return info.count, nil
+}
+
+func gatherStrings(fd int, dev string, n uint32) ([]string, error) {
+	hdrSize := int(unsafe.Sizeof(ethtoolGStringsHdr{}))
+	buf := make([]byte, hdrSize+int(n)*ethStringLen)
+	hdr := (*ethtoolGStringsHdr)(unsafe.Pointer(&buf[0]))
+	hdr.cmd = ethtoolGStrings
+	hdr.stringSet = ethSSStats
+	if err := doIoctl(fd, dev, unsafe.Pointer(hdr)); err != nil {
+		return nil, err
+	}
+	return parseGStrings(buf, n), nil
+}
+
+// parseGStrings extracts the n fixed-width, NUL-terminated strings
+// ETHTOOL_GSTRINGS wrote after its header into buf. Split out from
+// gatherStrings so the offset math can be unit-tested without an ioctl.
+func parseGStrings(buf []byte, n uint32) []string {
+	hdrSize := int(unsafe.Sizeof(ethtoolGStringsHdr{}))
+	names := make([]string, n)
+	for i := uint32(0); i < n; i++ {
+		off := hdrSize + int(i)*ethStringLen
+		names[i] = cString(buf[off : off+ethStringLen])
+	}
+	return names
+}
+
+func gatherStats(fd int, dev string, n uint32) ([]uint64, error) {
+	hdrSize := int(unsafe.Sizeof(ethtoolStatsHdr{}))
+	buf := make([]byte, hdrSize+int(n)*8)
+	hdr := (*ethtoolStatsHdr)(unsafe.Pointer(&buf[0]))
+	hdr.cmd = ethtoolGStats
+	hdr.nStats = n
+	if err := doIoctl(fd, dev, unsafe.Pointer(hdr)); err != nil {
+		return nil, err
+	}
+	return parseGStats(buf, n), nil
+}
+
+// parseGStats extracts the n native-endian uint64 counters ETHTOOL_GSTATS
+// wrote after its header into buf. Split out from gatherStats so the
+// offset math can be unit-tested without an ioctl.
+func parseGStats(buf []byte, n uint32) []uint64 {
+	hdrSize := int(unsafe.Sizeof(ethtoolStatsHdr{}))
+	values := make([]uint64, n)
+	for i := uint32(0); i < n; i++ {
+		off := hdrSize + int(i)*8
+		values[i] = *(*uint64)(unsafe.Pointer(&buf[off]))
+	}
+	return values
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
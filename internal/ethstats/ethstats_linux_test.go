@@ -0,0 +1,60 @@
+//go:build linux
+
+package ethstats
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestCString(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"nul terminated", []byte("rx_packets\x00\x00\x00\x00"), "rx_packets"},
+		{"fills buffer", []byte("rx_packets"), "rx_packets"},
+		{"empty", []byte("\x00\x00\x00\x00"), ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := cString(c.in); got != c.want {
+				t.Errorf("cString(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseGStrings(t *testing.T) {
+	hdrSize := int(unsafe.Sizeof(ethtoolGStringsHdr{}))
+	n := uint32(3)
+	buf := make([]byte, hdrSize+int(n)*ethStringLen)
+	for i, name := range []string{"rx_packets", "tx_packets", "queue_0_rx_bytes"} {
+		off := hdrSize + i*ethStringLen
+		copy(buf[off:off+ethStringLen], name)
+	}
+
+	got := parseGStrings(buf, n)
+	want := []string{"rx_packets", "tx_packets", "queue_0_rx_bytes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGStrings() = %v, want %v", got, want)
+	}
+}
+
+func TestParseGStats(t *testing.T) {
+	hdrSize := int(unsafe.Sizeof(ethtoolStatsHdr{}))
+	n := uint32(3)
+	buf := make([]byte, hdrSize+int(n)*8)
+	for i, v := range []uint64{0, 1, 1 << 40} {
+		off := hdrSize + i*8
+		*(*uint64)(unsafe.Pointer(&buf[off])) = v
+	}
+
+	got := parseGStats(buf, n)
+	want := []uint64{0, 1, 1 << 40}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGStats() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ethstats
+
+// Gather always returns ErrUnsupported on non-Linux platforms; callers
+// should fall back to shelling out to the ethtool binary.
+func Gather(dev string) ([]Stat, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,89 @@
+// Package logging provides a small leveled logger for ethtoolMetrics,
+// plus an ETHTOOL_METRICS_LOG switch for subsystem-scoped debug output
+// that's independent of the overall log level.
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Tag scopes a debug line to a subsystem so it can be turned on
+// independently via ETHTOOL_METRICS_LOG (a comma list, e.g. "net,parse").
+type Tag string
+
+const (
+	Net   Tag = "net"   // raw ethtool -S responses
+	Parse Tag = "parse" // each parsed measurement
+	Send  Tag = "send"  // each spectatord UDP write
+)
+
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+// tagLogger is always at debug level so Tagged's output doesn't depend on
+// SetLevel: ETHTOOL_METRICS_LOG is a separate, always-on switch.
+var tagLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+var enabledTags = parseTags(os.Getenv("ETHTOOL_METRICS_LOG"))
+
+func parseTags(v string) map[Tag]bool {
+	tags := make(map[Tag]bool)
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags[Tag(t)] = true
+		}
+	}
+	return tags
+}
+
+// SetLevel sets the process-wide minimum log level, one of "debug",
+// "info", "warn", or "error" (defaulting to "info" for anything else).
+func SetLevel(level string) {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl}))
+}
+
+func Debugf(format string, args ...any) {
+	logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func Infof(format string, args ...any) {
+	logger.Info(fmt.Sprintf(format, args...))
+}
+
+func Warnf(format string, args ...any) {
+	logger.Warn(fmt.Sprintf(format, args...))
+}
+
+func Errorf(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+}
+
+// Fatalf logs at error level and terminates the process, for startup
+// failures there's no reasonable way to run without (e.g. no interfaces
+// to query, or a listener that can't bind).
+func Fatalf(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// Tagged logs a debug line for tag only if tag was named in
+// ETHTOOL_METRICS_LOG, regardless of the overall log level.
+func Tagged(tag Tag, format string, args ...any) {
+	if !enabledTags[tag] {
+		return
+	}
+	tagLogger.Debug(fmt.Sprintf(format, args...))
+}
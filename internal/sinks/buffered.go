@@ -0,0 +1,189 @@
+package sinks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dmuino/ethtoolMetrics/internal/logging"
+)
+
+// DefaultBufferCapacity is the default number of measurements the ring
+// buffer holds before it starts dropping (or spooling) the overflow.
+const DefaultBufferCapacity = 10000
+
+const (
+	flushBatchSize     = 256
+	initialBackoff     = 500 * time.Millisecond
+	maxBackoff         = 30 * time.Second
+	dropReportInterval = 30 * time.Second
+)
+
+// BufferedSink decouples collection from delivery: Send enqueues
+// measurements into a bounded ring buffer and returns immediately, while a
+// background flusher drains the buffer into the underlying sink. A write
+// failure backs the flusher off exponentially; measurements keep being
+// accepted up to the buffer's capacity in the meantime. Once it's full, the
+// oldest queued measurement is dropped to make room for each new one; if a
+// spool is configured, the new measurement is spooled to disk instead.
+type BufferedSink struct {
+	underlying Sink
+	capacity   int
+	spool      *spool
+
+	mu      sync.Mutex
+	queue   []Measurement
+	dropped uint64
+	notify  chan struct{}
+}
+
+// NewBufferedSink wraps underlying with a ring buffer of at most capacity
+// measurements. When spoolDir is non-empty, measurements that would
+// otherwise be dropped once the in-memory buffer is full are appended to
+// an on-disk segmented log under spoolDir instead, and anything left over
+// from a previous run is replayed back into the buffer on startup.
+func NewBufferedSink(underlying Sink, capacity int, spoolDir string) (*BufferedSink, error) {
+	b := &BufferedSink{
+		underlying: underlying,
+		capacity:   capacity,
+		notify:     make(chan struct{}, 1),
+	}
+	if spoolDir != "" {
+		sp, replayed, dropped, err := openSpool(spoolDir, capacity)
+		if err != nil {
+			return nil, err
+		}
+		b.spool = sp
+		b.queue = append(b.queue, replayed...)
+		b.dropped += uint64(dropped)
+		if len(replayed) > 0 {
+			logging.Infof("buffer: replayed %d measurements spooled by a previous run", len(replayed))
+		}
+		if dropped > 0 {
+			logging.Warnf("buffer: spooled backlog exceeded buffer capacity (%d); dropped %d measurements on replay", capacity, dropped)
+		}
+	}
+	go b.flushLoop()
+	return b, nil
+}
+
+// Send enqueues ms for asynchronous delivery and always returns nil; a
+// full sink or network outage is handled by dropping (or spooling) the
+// overflow rather than by blocking the caller or failing the batch.
+func (b *BufferedSink) Send(ms []Measurement) error {
+	b.mu.Lock()
+	for _, m := range ms {
+		if len(b.queue) >= b.capacity {
+			if b.spool != nil {
+				if err := b.spool.append(m); err != nil {
+					logging.Warnf("buffer: failed to spool measurement to disk: %v", err)
+				}
+				continue
+			}
+			b.queue = b.queue[1:]
+			b.dropped++
+		}
+		b.queue = append(b.queue, m)
+	}
+	b.mu.Unlock()
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (b *BufferedSink) drain(n int) []Measurement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.queue) < n {
+		n = len(b.queue)
+	}
+	if n == 0 {
+		return nil
+	}
+	batch := append([]Measurement(nil), b.queue[:n]...)
+	b.queue = b.queue[n:]
+	return batch
+}
+
+// requeue puts a failed delivery batch back at the front of the queue
+// (it's older than anything already queued) and, like Send, enforces
+// capacity on the result rather than letting a sustained outage grow the
+// buffer past its configured bound.
+func (b *BufferedSink) requeue(batch []Measurement) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queue = append(batch, b.queue...)
+	if over := len(b.queue) - b.capacity; over > 0 {
+		overflow := b.queue[:over]
+		b.queue = b.queue[over:]
+		for _, m := range overflow {
+			if b.spool != nil {
+				if err := b.spool.append(m); err != nil {
+					logging.Warnf("buffer: failed to spool measurement to disk: %v", err)
+				}
+				continue
+			}
+			b.dropped++
+		}
+	}
+}
+
+func (b *BufferedSink) takeDropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := b.dropped
+	b.dropped = 0
+	return n
+}
+
+// flushLoop drains the buffer into the underlying sink until the process
+// exits; it never returns.
+func (b *BufferedSink) flushLoop() {
+	backoff := initialBackoff
+	failing := false
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	reportTicker := time.NewTicker(dropReportInterval)
+	defer reportTicker.Stop()
+
+	for {
+		select {
+		case <-b.notify:
+		case <-ticker.C:
+		case <-reportTicker.C:
+			// Report drops even when the sink is healthy and never fails —
+			// e.g. a buffer that's simply too small for sustained
+			// throughput — instead of only surfacing them on the
+			// failing-to-healthy transition below.
+			if dropped := b.takeDropped(); dropped > 0 {
+				logging.Warnf("buffer: %d measurements dropped in the last %v", dropped, dropReportInterval)
+			}
+			continue
+		}
+
+		batch := b.drain(flushBatchSize)
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := b.underlying.Send(batch); err != nil {
+			logging.Warnf("buffer: sink write failed, backing off %v: %v", backoff, err)
+			b.requeue(batch)
+			failing = true
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+
+		if failing {
+			if dropped := b.takeDropped(); dropped > 0 {
+				logging.Infof("buffer: sink recovered; %d measurements were dropped during the outage", dropped)
+			}
+			failing = false
+			backoff = initialBackoff
+		}
+	}
+}
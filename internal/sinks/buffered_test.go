@@ -0,0 +1,71 @@
+package sinks
+
+import "testing"
+
+func TestBufferedSinkSendEvictsOldestThenAppends(t *testing.T) {
+	b := &BufferedSink{capacity: 3}
+
+	for i := int64(0); i < 3; i++ {
+		if err := b.Send([]Measurement{{Name: "eth.rxPackets", Value: i}}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if len(b.queue) != 3 {
+		t.Fatalf("queue length = %d, want 3", len(b.queue))
+	}
+
+	// The buffer is now at capacity; the next Send must evict the oldest
+	// entry (value 0) and still enqueue the new one (value 3), leaving the
+	// queue at capacity rather than shrinking it.
+	if err := b.Send([]Measurement{{Name: "eth.rxPackets", Value: 3}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(b.queue) != 3 {
+		t.Fatalf("queue length after overflow = %d, want 3", len(b.queue))
+	}
+	want := []int64{1, 2, 3}
+	for i, m := range b.queue {
+		if m.Value != want[i] {
+			t.Errorf("queue[%d].Value = %d, want %d", i, m.Value, want[i])
+		}
+	}
+	if b.dropped != 1 {
+		t.Errorf("dropped = %d, want 1", b.dropped)
+	}
+}
+
+func TestBufferedSinkRequeueEnforcesCapacity(t *testing.T) {
+	b := &BufferedSink{capacity: 3}
+	b.queue = []Measurement{{Value: 2}}
+
+	// Requeuing a failed delivery batch puts it back in front of the queue;
+	// here the merged length exactly fills capacity, so nothing is evicted.
+	b.requeue([]Measurement{{Value: 0}, {Value: 1}})
+	if len(b.queue) != 3 {
+		t.Fatalf("queue length = %d, want 3", len(b.queue))
+	}
+	want := []int64{0, 1, 2}
+	for i, m := range b.queue {
+		if m.Value != want[i] {
+			t.Errorf("queue[%d].Value = %d, want %d", i, m.Value, want[i])
+		}
+	}
+
+	// A second requeue pushes the merged length to 5 against a capacity of
+	// 3: the two oldest entries (the front of the merged batch+queue) must
+	// be evicted, leaving the queue unchanged and at capacity rather than
+	// growing past it.
+	b.requeue([]Measurement{{Value: -2}, {Value: -1}})
+	if len(b.queue) != 3 {
+		t.Fatalf("queue length after overflow = %d, want 3", len(b.queue))
+	}
+	want = []int64{0, 1, 2}
+	for i, m := range b.queue {
+		if m.Value != want[i] {
+			t.Errorf("queue[%d].Value = %d, want %d", i, m.Value, want[i])
+		}
+	}
+	if b.dropped != 2 {
+		t.Errorf("dropped = %d, want 2", b.dropped)
+	}
+}
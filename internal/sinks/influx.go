@@ -0,0 +1,83 @@
+package sinks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InfluxSink writes measurements using InfluxDB line protocol, over UDP or
+// as HTTP /write requests depending on the target URL's scheme.
+type InfluxSink struct {
+	httpURL string
+	udpConn net.Conn
+	client  *http.Client
+}
+
+// NewInfluxSink builds a sink from a URL such as udp://host:8089 or
+// http://host:8086/write?db=metrics.
+func NewInfluxSink(rawURL string) (*InfluxSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("sinks: invalid influx url %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &InfluxSink{udpConn: conn}, nil
+	case "http", "https":
+		return &InfluxSink{httpURL: rawURL, client: &http.Client{Timeout: 5 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("sinks: unsupported influx scheme %q", u.Scheme)
+	}
+}
+
+func toLineProtocol(m Measurement, ts time.Time) string {
+	var b strings.Builder
+	b.WriteString("eth")
+	if len(m.Tags) > 0 {
+		keys := make([]string, 0, len(m.Tags))
+		for k := range m.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteByte(',')
+			b.WriteString(k)
+			b.WriteByte('=')
+			b.WriteString(m.Tags[k])
+		}
+	}
+	fmt.Fprintf(&b, " %s=%di %d\n", m.Name, m.Value, ts.UnixNano())
+	return b.String()
+}
+
+// Send writes ms as line-protocol points, all stamped with the time of
+// this Send call.
+func (s *InfluxSink) Send(ms []Measurement) error {
+	now := time.Now()
+	var b strings.Builder
+	for _, m := range ms {
+		b.WriteString(toLineProtocol(m, now))
+	}
+	if s.udpConn != nil {
+		_, err := s.udpConn.Write([]byte(b.String()))
+		return err
+	}
+	resp, err := s.client.Post(s.httpURL, "text/plain; charset=utf-8", strings.NewReader(b.String()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sinks: influx write failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Package sinks delivers collected interface statistics to a metrics
+// backend. Measurement is the stable representation collectors produce;
+// each Sink implementation only needs to know how to serialize and
+// deliver it.
+package sinks
+
+import "fmt"
+
+// Measurement is a single named metric sample with its labels.
+type Measurement struct {
+	Name  string
+	Tags  map[string]string
+	Value int64
+}
+
+// Sink delivers a batch of measurements to a metrics backend.
+type Sink interface {
+	Send(ms []Measurement) error
+}
+
+// New builds the Sink named by kind ("spectatord", "influx", or "statsd"),
+// connecting it to target (a hostname:port or, for influx, a udp:// or
+// http(s):// URL).
+func New(kind, target string) (Sink, error) {
+	switch kind {
+	case "spectatord", "":
+		if target == "" {
+			target = DefaultSpectatordAddress
+		}
+		return NewSpectatordSink(target)
+	case "influx":
+		return NewInfluxSink(target)
+	case "statsd":
+		return NewStatsdSink(target)
+	default:
+		return nil, fmt.Errorf("sinks: unknown sink kind %q", kind)
+	}
+}
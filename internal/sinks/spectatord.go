@@ -0,0 +1,97 @@
+package sinks
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/dmuino/ethtoolMetrics/internal/logging"
+)
+
+// DefaultSpectatordAddress is where spectatord listens by default.
+const DefaultSpectatordAddress = "127.0.0.1:1234"
+
+// SpectatordSink sends measurements to spectatord using Atlas's
+// C:name,tag=val:value UDP wire format.
+type SpectatordSink struct {
+	address string
+	c       net.Conn
+	mu      sync.Mutex
+}
+
+// NewSpectatordSink dials address (host:port) over UDP.
+func NewSpectatordSink(address string) (*SpectatordSink, error) {
+	s := &SpectatordSink{address: address}
+	err := s.initConn()
+	return s, err
+}
+
+func (s *SpectatordSink) initConn() (err error) {
+	if s.c != nil {
+		_ = s.c.Close()
+	}
+	s.c, err = net.Dial("udp", s.address)
+	return err
+}
+
+func toSpectatordLine(m Measurement) []byte {
+	var b bytes.Buffer
+	b.Grow(32)
+	b.WriteString("C:")
+	b.WriteString(m.Name)
+	for k, v := range m.Tags {
+		b.WriteByte(',')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(v)
+	}
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatInt(m.Value, 10))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func (s *SpectatordSink) sendBatch(batch [][]byte) (err error) {
+	chunk := bytes.Join(batch, nil)
+	for retry := 1; retry <= 3; retry++ {
+		_, err = s.c.Write(chunk)
+		if err == nil {
+			logging.Tagged(logging.Send, "wrote %d bytes to spectatord at %s", len(chunk), s.address)
+			return
+		}
+		err = s.initConn() // close and reopen the connection before retrying
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Send is safe for concurrent use by multiple goroutines; each call holds
+// the connection lock for the duration of its batches.
+func (s *SpectatordSink) Send(ms []Measurement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updates := make([][]byte, len(ms))
+	for i, m := range ms {
+		updates[i] = toSpectatordLine(m)
+	}
+	beg := 0
+	end := len(updates)
+	for beg < end {
+		cur := minInt(beg+32, end)
+		if err := s.sendBatch(updates[beg:cur]); err != nil {
+			return err
+		}
+		beg = cur
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// spoolSegmentSize is the approximate size, in bytes, at which a spool
+// segment is rotated.
+const spoolSegmentSize = 4 * 1024 * 1024
+
+// spool is an append-only, segmented on-disk log used to hold
+// measurements the in-memory ring buffer couldn't fit during a sink
+// outage, so they survive a brief agent restart instead of being dropped.
+type spool struct {
+	dir string
+
+	mu      sync.Mutex
+	segment *os.File
+	written int64
+	seq     int
+}
+
+// openSpool prepares dir for use and replays any segments left over from a
+// previous run before opening a fresh segment for this process to append
+// to. Replay is capped at limit measurements so a long outage can't make
+// a single restart blow past the configured buffer capacity; anything
+// beyond limit is dropped and accounted for in the returned count.
+func openSpool(dir string, limit int) (*spool, []Measurement, int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, 0, fmt.Errorf("sinks: spool dir: %w", err)
+	}
+	s := &spool{dir: dir}
+	replayed, dropped, err := s.replay(limit)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if err := s.rotate(); err != nil {
+		return nil, nil, 0, err
+	}
+	return s, replayed, dropped, nil
+}
+
+func (s *spool) segmentPath(seq int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("segment-%06d.log", seq))
+}
+
+func (s *spool) rotate() error {
+	if s.segment != nil {
+		_ = s.segment.Close()
+	}
+	s.seq++
+	f, err := os.OpenFile(s.segmentPath(s.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segment = f
+	s.written = 0
+	return nil
+}
+
+func (s *spool) append(m Measurement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := s.segment.Write(line)
+	if err != nil {
+		return err
+	}
+	s.written += int64(n)
+	if s.written >= spoolSegmentSize {
+		return s.rotate()
+	}
+	return nil
+}
+
+// replay reads every segment left over from a previous run, in order, and
+// returns up to limit of the measurements they hold, deleting each segment
+// once read regardless of whether its contents fit under limit. The second
+// return value is the number of measurements that were read but discarded
+// because limit was reached first.
+func (s *spool) replay(limit int) ([]Measurement, int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "segment-") && strings.HasSuffix(e.Name(), ".log") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var ms []Measurement
+	dropped := 0
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if len(ms) >= limit {
+				dropped++
+				continue
+			}
+			var m Measurement
+			if err := json.Unmarshal(scanner.Bytes(), &m); err == nil {
+				ms = append(ms, m)
+			}
+		}
+		f.Close()
+		_ = os.Remove(path)
+	}
+	return ms, dropped, nil
+}
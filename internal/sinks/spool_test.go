@@ -0,0 +1,57 @@
+package sinks
+
+import (
+	"testing"
+)
+
+func TestSpoolReplayCapAtLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	sp, replayed, dropped, err := openSpool(dir, 1000)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := sp.append(Measurement{Name: "eth.rxPackets", Value: int64(i)}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// Simulate a fresh process picking up the segments a previous run left
+	// behind, with a buffer capacity smaller than the spooled backlog.
+	_, replayed, dropped, err = openSpool(dir, 3)
+	if err != nil {
+		t.Fatalf("openSpool (replay): %v", err)
+	}
+	if len(replayed) != 3 {
+		t.Fatalf("replayed = %d measurements, want 3", len(replayed))
+	}
+	if dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", dropped)
+	}
+	for i, m := range replayed {
+		if m.Value != int64(i) {
+			t.Errorf("replayed[%d].Value = %d, want %d", i, m.Value, i)
+		}
+	}
+}
+
+func TestSpoolReplayUnderLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	sp, _, _, err := openSpool(dir, 1000)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	if err := sp.append(Measurement{Name: "eth.rxPackets", Value: 1}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	_, replayed, dropped, err := openSpool(dir, 10)
+	if err != nil {
+		t.Fatalf("openSpool (replay): %v", err)
+	}
+	if len(replayed) != 1 || dropped != 0 {
+		t.Fatalf("replayed = %d (dropped %d), want 1 (dropped 0)", len(replayed), dropped)
+	}
+}
@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// StatsdSink writes measurements as dogstatsd-style counters, e.g.
+// eth.queue.packets:123|c|#dir:tx,queue:0
+type StatsdSink struct {
+	conn net.Conn
+}
+
+// NewStatsdSink dials address (host:port) over UDP.
+func NewStatsdSink(address string) (*StatsdSink, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdSink{conn: conn}, nil
+}
+
+func toStatsdLine(m Measurement) string {
+	var b strings.Builder
+	b.WriteString(m.Name)
+	fmt.Fprintf(&b, ":%d|c", m.Value)
+	if len(m.Tags) > 0 {
+		keys := make([]string, 0, len(m.Tags))
+		for k := range m.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		tags := make([]string, len(keys))
+		for i, k := range keys {
+			tags[i] = k + ":" + m.Tags[k]
+		}
+		b.WriteString("|#")
+		b.WriteString(strings.Join(tags, ","))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func (s *StatsdSink) Send(ms []Measurement) error {
+	for _, m := range ms {
+		if _, err := s.conn.Write([]byte(toStatsdLine(m))); err != nil {
+			return err
+		}
+	}
+	return nil
+}